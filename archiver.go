@@ -0,0 +1,67 @@
+package targz
+
+import (
+	"archive/tar"
+	"io"
+	"path/filepath"
+)
+
+// Archiver bundles the functions used to pack and unpack tar archives, similar to Docker's
+// pkg/archive Archiver. Replacing Tar/Untar lets callers substitute their own implementation
+// entirely, e.g. for tests; setting OnFile is enough to observe every entry without doing so.
+type Archiver struct {
+	// Tar packs src (plus any TarOptions.IncludePaths) into a gzip compressed tar stream.
+	Tar func(src string, opts *TarOptions) (io.ReadCloser, error)
+	// Untar unpacks the tar stream read from r into dst.
+	Untar func(r io.Reader, dst string, opts *TarOptions) error
+	// OnFile, if set, is invoked for every entry written during Tar or read during Untar.
+	OnFile func(path string, hdr *tar.Header) error
+}
+
+// DefaultArchiver implements Archiver using the same walking, compression and breakout
+// protection as the package-level Compress/Extract functions.
+var DefaultArchiver = NewArchiver()
+
+// NewArchiver returns an Archiver whose Tar and Untar fields behave like the package-level
+// Compress/Extract functions. OnFile is left nil; set it to observe every packed or
+// unpacked entry without replacing Tar/Untar altogether.
+func NewArchiver() *Archiver {
+	a := &Archiver{}
+	a.Tar = func(src string, opts *TarOptions) (io.ReadCloser, error) {
+		return a.tar(src, opts)
+	}
+	a.Untar = func(r io.Reader, dst string, opts *TarOptions) error {
+		return a.untar(r, dst, opts)
+	}
+
+	return a
+}
+
+// Packs src into a gzip compressed tar stream, invoking a.OnFile for every entry written.
+func (a *Archiver) tar(src string, opts *TarOptions) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &TarOptions{}
+	}
+
+	src = stripTrailingSlashes(src)
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(compressStreamWithCompression(src, pw, filepath.Dir(src), Gzip, *opts, a.OnFile))
+	}()
+
+	return pr, nil
+}
+
+// Unpacks the tar stream read from r into dst, invoking a.OnFile for every entry read.
+func (a *Archiver) untar(r io.Reader, dst string, opts *TarOptions) error {
+	if opts == nil {
+		opts = &TarOptions{}
+	}
+
+	return extractStream(r, dst, *opts, a.OnFile)
+}
@@ -0,0 +1,117 @@
+package targz
+
+import (
+	"archive/tar"
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"os"
+)
+
+func TestCompressExtractCodecRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{Xz, Zstd} {
+		compression := compression
+		t.Run(compressionName(compression), func(t *testing.T) {
+			srcDir := writeSampleTree(t)
+			opts := Options{Compression: compression}
+
+			archivePath := filepath.Join(t.TempDir(), "archive")
+			if err := CompressWithOptions(srcDir, archivePath, opts); err != nil {
+				t.Fatalf("CompressWithOptions: %v", err)
+			}
+
+			archiveBytes, err := os.ReadFile(archivePath)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if detected := DetectCompression(archiveBytes); detected != compression {
+				t.Fatalf("DetectCompression: got %v, want %v", detected, compression)
+			}
+
+			destDir := t.TempDir()
+			if err := Extract(archivePath, destDir); err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(destDir, "payload", "file.txt"))
+			if err != nil {
+				t.Fatalf("ReadFile extracted: %v", err)
+			}
+			if string(got) != "parallel gzip payload" {
+				t.Fatalf("got %q", got)
+			}
+		})
+	}
+}
+
+// TestExtractBzip2Fixture builds a tar archive with the stdlib and compresses it with the
+// system bzip2 binary (Go's compress/bzip2 can only decode, never encode), then verifies
+// DetectCompression/ExtractStream can unpack it even though compressorFor refuses to write
+// the Bzip2 codec.
+func TestExtractBzip2Fixture(t *testing.T) {
+	bzip2Path, err := exec.LookPath("bzip2")
+	if err != nil {
+		t.Skip("bzip2 binary not available")
+	}
+
+	var tarBuf bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBuf)
+	const content = "bzip2 fixture payload"
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name: "payload/file.txt",
+		Mode: 0644,
+		Size: int64(len(content)),
+	}); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	if _, err := tarWriter.Write([]byte(content)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("tarWriter.Close: %v", err)
+	}
+
+	cmd := exec.Command(bzip2Path, "-c")
+	cmd.Stdin = &tarBuf
+	var compressed bytes.Buffer
+	cmd.Stdout = &compressed
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("bzip2: %v", err)
+	}
+
+	if detected := DetectCompression(compressed.Bytes()); detected != Bzip2 {
+		t.Fatalf("DetectCompression: got %v, want Bzip2", detected)
+	}
+
+	destDir := t.TempDir()
+	if err := ExtractStream(bytes.NewReader(compressed.Bytes()), destDir); err != nil {
+		t.Fatalf("ExtractStream: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(destDir, "payload", "file.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func compressionName(c Compression) string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Uncompressed:
+		return "uncompressed"
+	case Bzip2:
+		return "bzip2"
+	case Xz:
+		return "xz"
+	case Zstd:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
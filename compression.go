@@ -0,0 +1,181 @@
+package targz
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+)
+
+// compressionLevel is the level passed to gzip/pgzip writers, overridable via
+// SetCompressionLevel. It follows the same scale as compress/gzip.
+var compressionLevel = gzip.DefaultCompression
+
+// SetCompressionLevel overrides the compression level used for the Gzip codec on every
+// subsequent Compress/CompressStream call, using the same scale as compress/gzip
+// (gzip.BestSpeed..gzip.BestCompression).
+func SetCompressionLevel(level int) {
+	compressionLevel = level
+}
+
+// unpigzPath holds the location of an unpigz binary found in $PATH at startup, or "" if
+// none was found. Detected once at init time the same way Docker's pkg/archive does it.
+var unpigzPath string
+
+func init() {
+	if path, err := exec.LookPath("unpigz"); err == nil {
+		unpigzPath = path
+	}
+}
+
+// Compression identifies the codec a tar archive is (or should be) compressed with.
+type Compression int
+
+// The compressions targz knows how to deal with. Gzip is first and therefore the zero
+// value, matching the package's historic gzip-only behavior: a zero-value Options{} passed
+// to CompressWithOptions still produces a .tar.gz, not a plain tar. Uncompressed must be
+// selected explicitly to skip compression entirely.
+const (
+	Gzip Compression = iota
+	Uncompressed
+	Bzip2
+	Xz
+	Zstd
+)
+
+// magicNumbers holds the leading bytes used to recognize a compressed stream, longest
+// prefix first so DetectCompression can match unambiguously.
+var magicNumbers = []struct {
+	compression Compression
+	magic       []byte
+}{
+	{Xz, []byte{0xFD, 0x37, 0x7A, 0x58, 0x5A, 0x00}},
+	{Zstd, []byte{0x28, 0xB5, 0x2F, 0xFD}},
+	{Gzip, []byte{0x1F, 0x8B, 0x08}},
+	{Bzip2, []byte{0x42, 0x5A, 0x68}},
+}
+
+// DetectCompression inspects the leading bytes of header and returns the Compression they
+// match, or Uncompressed if none of the known magic numbers are found.
+func DetectCompression(header []byte) Compression {
+	for _, candidate := range magicNumbers {
+		if bytes.HasPrefix(header, candidate.magic) {
+			return candidate.compression
+		}
+	}
+
+	return Uncompressed
+}
+
+// DecompressStream peeks at the first bytes of r to detect the compression codec in use and
+// returns a reader that transparently decompresses the underlying stream. Peeking is done
+// through a bufio.Reader so no bytes are consumed from r beyond what the caller reads back.
+func DecompressStream(r io.Reader) (io.ReadCloser, error) {
+	return decompressStream(r, TarOptions{})
+}
+
+// Same as DecompressStream but honors opts.Parallel and opts.AllowExec for the Gzip codec.
+func decompressStream(r io.Reader, opts TarOptions) (io.ReadCloser, error) {
+	buffered := bufio.NewReaderSize(r, 6)
+	header, err := buffered.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch DetectCompression(header) {
+	case Gzip:
+		if opts.AllowExec && unpigzPath != "" {
+			return unpigzReader(buffered)
+		}
+		if opts.Parallel {
+			return pgzip.NewReader(buffered)
+		}
+		return gzip.NewReader(buffered)
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(buffered)), nil
+	case Xz:
+		xzReader, err := xz.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xzReader), nil
+	case Zstd:
+		zstdReader, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader.IOReadCloser(), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
+
+// unpigzReader shells out to the unpigz binary found in $PATH to decompress r, which tends
+// to outperform pgzip's in-process decompression on very large streams.
+func unpigzReader(r io.Reader) (io.ReadCloser, error) {
+	cmd := exec.Command(unpigzPath, "-dc")
+	cmd.Stdin = r
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &execReadCloser{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// execReadCloser waits for the wrapped command to exit once the caller is done reading.
+type execReadCloser struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (e *execReadCloser) Close() error {
+	if err := e.ReadCloser.Close(); err != nil {
+		return err
+	}
+
+	return e.cmd.Wait()
+}
+
+// compressorFor wraps w in a writer that compresses everything written to it using
+// compression, or returns an error if compression can't be used for writing. parallel
+// selects pgzip over the stdlib's compress/gzip for the Gzip codec.
+func compressorFor(w io.Writer, compression Compression, parallel bool) (io.WriteCloser, error) {
+	switch compression {
+	case Uncompressed:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if parallel {
+			return pgzip.NewWriterLevel(w, compressionLevel)
+		}
+		return gzip.NewWriterLevel(w, compressionLevel)
+	case Xz:
+		return xz.NewWriter(w)
+	case Zstd:
+		return zstd.NewWriter(w)
+	case Bzip2:
+		return nil, fmt.Errorf("targz: compressing to bzip2 is not supported, the format can only be extracted")
+	default:
+		return nil, fmt.Errorf("targz: unknown compression %d", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
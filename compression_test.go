@@ -0,0 +1,108 @@
+package targz
+
+import (
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeSampleTree(t *testing.T) string {
+	t.Helper()
+
+	srcRoot := t.TempDir()
+	srcDir := filepath.Join(srcRoot, "payload")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("parallel gzip payload"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return srcDir
+}
+
+func TestCompressExtractParallelGzip(t *testing.T) {
+	srcDir := writeSampleTree(t)
+	opts := Options{Compression: Gzip, TarOptions: TarOptions{Parallel: true}}
+
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := CompressWithOptions(srcDir, archivePath, opts); err != nil {
+		t.Fatalf("CompressWithOptions: %v", err)
+	}
+
+	destDir := t.TempDir()
+	if err := ExtractWithOptions(archivePath, destDir, opts.TarOptions); err != nil {
+		t.Fatalf("ExtractWithOptions: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(destDir, "payload", "file.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "parallel gzip payload" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSetCompressionLevel(t *testing.T) {
+	original := compressionLevel
+	defer func() { compressionLevel = original }()
+
+	for _, level := range []int{gzip.BestSpeed, gzip.BestCompression} {
+		SetCompressionLevel(level)
+
+		srcDir := writeSampleTree(t)
+		archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+		if err := Compress(srcDir, archivePath); err != nil {
+			t.Fatalf("Compress at level %d: %v", level, err)
+		}
+
+		destDir := t.TempDir()
+		if err := Extract(archivePath, destDir); err != nil {
+			t.Fatalf("Extract at level %d: %v", level, err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(destDir, "payload", "file.txt"))
+		if err != nil {
+			t.Fatalf("ReadFile at level %d: %v", level, err)
+		}
+		if string(got) != "parallel gzip payload" {
+			t.Fatalf("level %d: got %q", level, got)
+		}
+	}
+}
+
+// TestUnpigzReader exercises the AllowExec/unpigzReader path without requiring unpigz to be
+// installed: gzip accepts the same "-dc" flags unpigz does, so it stands in as the external
+// decompressor for the purpose of exercising execReadCloser's close-then-wait behavior.
+func TestUnpigzReader(t *testing.T) {
+	gzipPath, err := exec.LookPath("gzip")
+	if err != nil {
+		t.Skip("gzip binary not available")
+	}
+
+	originalPath := unpigzPath
+	unpigzPath = gzipPath
+	defer func() { unpigzPath = originalPath }()
+
+	srcDir := writeSampleTree(t)
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := Compress(srcDir, archivePath); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+
+	destDir := t.TempDir()
+	if err := ExtractWithOptions(archivePath, destDir, TarOptions{AllowExec: true}); err != nil {
+		t.Fatalf("ExtractWithOptions: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(destDir, "payload", "file.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "parallel gzip payload" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,175 @@
+package targz
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Extract reads the archive archivePath points to and extracts its contents into the
+// directory outputFilePath points to, creating it if it doesn't exist yet.
+// It returns potential errors to be checked or nil if everything works.
+// It is a thin wrapper over DefaultArchiver.Untar, see Archiver for how to customize it.
+func Extract(archivePath, outputFilePath string) (err error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return DefaultArchiver.Untar(file, outputFilePath, &TarOptions{})
+}
+
+// ExtractWithOptions behaves like Extract but lets callers pass TarOptions controlling
+// ownership of the extracted files.
+func ExtractWithOptions(archivePath, outputFilePath string, opts TarOptions) (err error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return ExtractStreamWithOptions(file, outputFilePath, opts)
+}
+
+// ExtractStream reads a tar archive from r, auto-detecting the compression codec it was
+// written with via DetectCompression, and extracts its contents into the directory
+// outputFilePath points to, creating it if it doesn't exist yet.
+// It returns potential errors to be checked or nil if everything works.
+func ExtractStream(r io.Reader, outputFilePath string) (err error) {
+	return ExtractStreamWithOptions(r, outputFilePath, TarOptions{})
+}
+
+// ExtractStreamWithOptions behaves like ExtractStream but lets callers pass TarOptions
+// controlling ownership of the extracted files.
+func ExtractStreamWithOptions(r io.Reader, outputFilePath string, opts TarOptions) (err error) {
+	return extractStream(r, outputFilePath, opts, nil)
+}
+
+// Same as ExtractStreamWithOptions but additionally invokes onFile for every entry read
+// (see Archiver.OnFile).
+func extractStream(r io.Reader, outputFilePath string, opts TarOptions, onFile func(string, *tar.Header) error) (err error) {
+	decompressReader, err := decompressStream(r, opts)
+	if err != nil {
+		return err
+	}
+	defer decompressReader.Close()
+
+	if err = os.MkdirAll(outputFilePath, 0755); err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(decompressReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := writeEntry(header, tarReader, outputFilePath, opts); err != nil {
+			return err
+		}
+
+		if onFile != nil {
+			if err := onFile(header.Name, header); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Writes a single tar entry into destDir, honoring its type (directory, symlink or regular
+// file) and refusing to let it break out of destDir. See ErrBreakout.
+func writeEntry(header *tar.Header, r io.Reader, destDir string, opts TarOptions) error {
+	target, err := safeJoin(destDir, header.Name)
+	if err != nil {
+		return err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, os.FileMode(header.Mode)); err != nil {
+			return err
+		}
+		return chownEntry(target, header, opts, false)
+	case tar.TypeSymlink:
+		if err := safeSymlinkTarget(destDir, header.Name, header.Linkname); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			return err
+		}
+		return chownEntry(target, header, opts, true)
+	case tar.TypeLink:
+		linkTarget, err := safeJoin(destDir, header.Linkname)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Link(linkTarget, target)
+	default:
+		if err := checkParentSymlinks(destDir, target); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		if _, err := io.Copy(out, r); err != nil {
+			return err
+		}
+
+		return chownEntry(target, header, opts, false)
+	}
+}
+
+// geteuid is a seam over os.Geteuid so tests can simulate running as a non-root user.
+var geteuid = os.Geteuid
+
+// Applies the uid/gid stored in header to name, remapping them through opts.UIDMap/GIDMap.
+// Symlinks are skipped when opts.NoLchown is set. Chowning is skipped entirely unless the
+// caller asked for id remapping or is running as root: an ordinary user extracting a
+// foreign archive (e.g. a root-owned release tarball) can't chown to its stored ids anyway,
+// and failing the whole extraction with EPERM for that is worse than leaving ownership as
+// the extracting user. Permission errors that slip through regardless are swallowed too.
+func chownEntry(name string, header *tar.Header, opts TarOptions, isSymlink bool) error {
+	if isSymlink && opts.NoLchown {
+		return nil
+	}
+
+	if geteuid() != 0 && len(opts.UIDMap) == 0 && len(opts.GIDMap) == 0 {
+		return nil
+	}
+
+	uid := remapID(header.Uid, opts.UIDMap)
+	gid := remapID(header.Gid, opts.GIDMap)
+
+	var err error
+	if isSymlink {
+		err = os.Lchown(name, uid, gid)
+	} else {
+		err = os.Chown(name, uid, gid)
+	}
+
+	if os.IsPermission(err) {
+		return nil
+	}
+
+	return err
+}
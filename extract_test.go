@@ -0,0 +1,147 @@
+package targz
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildArchive writes a gzip compressed tar archive containing entries and returns its bytes.
+func buildArchive(t *testing.T, entries []tar.Header, contents map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, header := range entries {
+		h := header
+		body := contents[h.Name]
+		h.Size = int64(len(body))
+		if err := tarWriter.WriteHeader(&h); err != nil {
+			t.Fatalf("WriteHeader(%s): %v", h.Name, err)
+		}
+		if body != "" {
+			if _, err := tarWriter.Write([]byte(body)); err != nil {
+				t.Fatalf("Write(%s): %v", h.Name, err)
+			}
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("tarWriter.Close: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("gzipWriter.Close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestExtractStreamRejectsPathTraversal(t *testing.T) {
+	archive := buildArchive(t, []tar.Header{
+		{Name: "../../etc/passwd", Typeflag: tar.TypeReg, Mode: 0644},
+	}, map[string]string{
+		"../../etc/passwd": "root:x:0:0::/root:/bin/sh\n",
+	})
+
+	destDir := t.TempDir()
+	err := ExtractStream(bytes.NewReader(archive), destDir)
+	if !errors.Is(err, ErrBreakout) {
+		t.Fatalf("expected ErrBreakout, got %v", err)
+	}
+}
+
+func TestExtractStreamRejectsSymlinkBreakout(t *testing.T) {
+	archive := buildArchive(t, []tar.Header{
+		{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: "../../../../etc", Mode: 0777},
+		{Name: "escape/passwd", Typeflag: tar.TypeReg, Mode: 0644},
+	}, map[string]string{
+		"escape/passwd": "pwned",
+	})
+
+	destDir := t.TempDir()
+	err := ExtractStream(bytes.NewReader(archive), destDir)
+	if !errors.Is(err, ErrBreakout) {
+		t.Fatalf("expected ErrBreakout, got %v", err)
+	}
+}
+
+func TestExtractStreamRejectsAbsoluteLinkTarget(t *testing.T) {
+	archive := buildArchive(t, []tar.Header{
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", Mode: 0777},
+	}, nil)
+
+	destDir := t.TempDir()
+	err := ExtractStream(bytes.NewReader(archive), destDir)
+	if !errors.Is(err, ErrBreakout) {
+		t.Fatalf("expected ErrBreakout, got %v", err)
+	}
+}
+
+func TestExtractStreamSkipsChownAsNonRootWithoutIDMap(t *testing.T) {
+	originalGeteuid := geteuid
+	geteuid = func() int { return 1000 }
+	defer func() { geteuid = originalGeteuid }()
+
+	archive := buildArchive(t, []tar.Header{
+		{Name: "foreign.txt", Typeflag: tar.TypeReg, Mode: 0644, Uid: 0, Gid: 0},
+	}, map[string]string{
+		"foreign.txt": "owned by root in the archive",
+	})
+
+	destDir := t.TempDir()
+	if err := ExtractStream(bytes.NewReader(archive), destDir); err != nil {
+		t.Fatalf("Extract as non-root of a foreign-uid archive should not fail: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(destDir, "foreign.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "owned by root in the archive" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestCompressExtractRoundTrip(t *testing.T) {
+	srcRoot := t.TempDir()
+	srcDir := filepath.Join(srcRoot, "payload")
+	subDir := filepath.Join(srcDir, "sub")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "top.txt"), []byte("top"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "nested.txt"), []byte("nested"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := Compress(srcDir, archivePath); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+
+	destDir := t.TempDir()
+	if err := Extract(archivePath, destDir); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	for relPath, want := range map[string]string{
+		"payload/top.txt":        "top",
+		"payload/sub/nested.txt": "nested",
+	} {
+		got, err := os.ReadFile(filepath.Join(destDir, relPath))
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", relPath, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", relPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package targz
+
+import (
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IDMap describes how to remap a user or group id found in an archive to a different id on
+// the host, the same way container runtimes remap ids for user namespaces.
+type IDMap struct {
+	ContainerID int
+	HostID      int
+	Size        int
+}
+
+// TarOptions parameterizes how Compress walks the source tree and how Extract restores it,
+// modeled on Docker's pkg/archive TarOptions.
+type TarOptions struct {
+	// IncludePaths adds extra root directories to the archive besides the single directory
+	// passed to Compress.
+	IncludePaths []string
+	// ExcludePatterns are doublestar glob patterns matched against each entry's
+	// archive-relative path; matching entries (and, for directories, their contents) are
+	// left out of the archive.
+	ExcludePatterns []string
+	// NoLchown disables chowning of symlinks while extracting.
+	NoLchown bool
+	// UIDMap and GIDMap remap the uid/gid stored in an archive entry to a different id
+	// while extracting. Ids with no matching entry in the map are left untouched.
+	UIDMap []IDMap
+	GIDMap []IDMap
+	// Deterministic zeroes mtimes, uid/gid and username/groupname fields before writing
+	// tar headers, so repeated runs over an unchanged tree produce byte-identical archives.
+	Deterministic bool
+	// Parallel uses a concurrent gzip implementation (klauspost/pgzip) instead of the
+	// stdlib's compress/gzip for both compressing and decompressing, trading memory for
+	// near-linear speedup across cores. Only takes effect for the Gzip codec.
+	Parallel bool
+	// AllowExec permits shelling out to an unpigz binary found in $PATH to decompress gzip
+	// streams, which tends to outperform pgzip on very large inputs. Leave unset in
+	// security-sensitive environments; has no effect if unpigz isn't installed.
+	AllowExec bool
+}
+
+// remapID looks up id in idMap and returns the corresponding host id, or id unchanged if no
+// entry in idMap covers it.
+func remapID(id int, idMap []IDMap) int {
+	for _, m := range idMap {
+		if id >= m.ContainerID && id < m.ContainerID+m.Size {
+			return m.HostID + (id - m.ContainerID)
+		}
+	}
+
+	return id
+}
+
+// matchesAny reports whether relPath matches any of the doublestar glob patterns.
+func matchesAny(patterns []string, relPath string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := doublestar.Match(pattern, relPath)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,52 @@
+package targz
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompressWithOptionsDeterministic(t *testing.T) {
+	srcRoot := t.TempDir()
+	srcDir := filepath.Join(srcRoot, "payload")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	opts := Options{TarOptions: TarOptions{Deterministic: true}}
+
+	firstPath := filepath.Join(t.TempDir(), "first.tar")
+	if err := CompressWithOptions(srcDir, firstPath, opts); err != nil {
+		t.Fatalf("CompressWithOptions (first): %v", err)
+	}
+
+	// Move the file's mtime forward between runs; a non-deterministic archive would
+	// pick this up and the byte comparison below would fail.
+	touched := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(srcDir, "file.txt"), touched, touched); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	secondPath := filepath.Join(t.TempDir(), "second.tar")
+	if err := CompressWithOptions(srcDir, secondPath, opts); err != nil {
+		t.Fatalf("CompressWithOptions (second): %v", err)
+	}
+
+	first, err := os.ReadFile(firstPath)
+	if err != nil {
+		t.Fatalf("ReadFile(first): %v", err)
+	}
+	second, err := os.ReadFile(secondPath)
+	if err != nil {
+		t.Fatalf("ReadFile(second): %v", err)
+	}
+
+	if !bytes.Equal(first, second) {
+		t.Fatalf("expected deterministic archives to be byte-identical")
+	}
+}
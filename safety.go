@@ -0,0 +1,94 @@
+package targz
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBreakout is returned by Extract and ExtractStream when an archive entry attempts to
+// write outside of the extraction destination directory, e.g. via a ".." path segment or a
+// symlink pointing outside of it.
+var ErrBreakout = errors.New("targz: archive entry breaks out of destination directory")
+
+// safeJoin joins destDir and name, returning ErrBreakout if name is absolute or the cleaned,
+// joined path would end up outside of destDir.
+func safeJoin(destDir, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", ErrBreakout
+	}
+
+	target := filepath.Join(destDir, filepath.Clean(name))
+
+	if !pathContains(destDir, target) {
+		return "", ErrBreakout
+	}
+
+	return target, nil
+}
+
+// safeSymlinkTarget verifies that linkname, found on the symlink entry named entryName,
+// resolves to a path within destDir. Relative link targets are resolved against the
+// directory the symlink itself lives in, mirroring filesystem symlink semantics.
+func safeSymlinkTarget(destDir, entryName, linkname string) error {
+	base := filepath.Dir(filepath.Join(destDir, filepath.Clean(entryName)))
+
+	resolved := linkname
+	if filepath.IsAbs(resolved) {
+		resolved = filepath.Clean(resolved)
+	} else {
+		resolved = filepath.Join(base, resolved)
+	}
+
+	if !pathContains(destDir, resolved) {
+		return ErrBreakout
+	}
+
+	return nil
+}
+
+// checkParentSymlinks walks target's ancestors up to destDir and fails with ErrBreakout if
+// any of them is a symlink pointing outside of destDir, so a regular file is never written
+// through a symlinked parent directory that breaks out of the extraction root.
+func checkParentSymlinks(destDir, target string) error {
+	for dir := filepath.Dir(target); pathContains(destDir, dir) && dir != destDir; dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		link, err := os.Readlink(dir)
+		if err != nil {
+			return err
+		}
+
+		resolved := link
+		if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(filepath.Dir(dir), resolved)
+		}
+
+		if !pathContains(destDir, resolved) {
+			return ErrBreakout
+		}
+	}
+
+	return nil
+}
+
+// pathContains reports whether target is destDir itself or lives somewhere underneath it.
+func pathContains(destDir, target string) bool {
+	rel, err := filepath.Rel(destDir, filepath.Clean(target))
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
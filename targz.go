@@ -7,19 +7,20 @@ package targz
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"syscall"
+	"time"
 )
 
 // Compress creates a archive from the folder inputFilePath points to in the file outputFilePath points to.
 // Only adds the last directory in inputFilePath to the archive, not the whole path.
 // It tries to create the directory structure outputFilePath contains if it doesn't exist.
 // It returns potential errors to be checked or nil if everything works.
+// It is a thin wrapper over DefaultArchiver.Tar, see Archiver for how to customize it.
 func Compress(inputFilePath, outputFilePath string) (err error) {
 	inputFilePath = stripTrailingSlashes(inputFilePath)
 	inputFilePath, outputFilePath, err = makeAbsolute(inputFilePath, outputFilePath)
@@ -36,12 +37,40 @@ func Compress(inputFilePath, outputFilePath string) (err error) {
 		}
 	}()
 
-	err = compress(inputFilePath, outputFilePath, filepath.Dir(inputFilePath))
+	archive, err := DefaultArchiver.Tar(inputFilePath, &TarOptions{})
 	if err != nil {
 		return err
 	}
+	defer archive.Close()
 
-	return nil
+	file, err := os.Create(outputFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(outputFilePath)
+		}
+	}()
+
+	if _, err = io.Copy(file, archive); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// CompressStream creates an archive from the folder inputFilePath points to and writes it to w
+// instead of a file on disk. Only adds the last directory in inputFilePath to the archive, not
+// the whole path. It returns potential errors to be checked or nil if everything works.
+func CompressStream(inputFilePath string, w io.Writer) (err error) {
+	inputFilePath = stripTrailingSlashes(inputFilePath)
+	inputFilePath, err = filepath.Abs(inputFilePath)
+	if err != nil {
+		return err
+	}
+
+	return compressStream(inputFilePath, w, filepath.Dir(inputFilePath))
 }
 
 // Creates all directories with os.MakedirAll and returns a function to remove the first created directory so cleanup is possible.
@@ -105,56 +134,116 @@ func makeAbsolute(inputFilePath, outputFilePath string) (string, string, error)
 	return inputFilePath, outputFilePath, err
 }
 
-// The main interaction with tar and gzip. Creates a archive and recursively adds all files in the directory.
-// The finished archive contains just the directory added, not any parents.
-// This is possible by giving the whole path except the final directory in subPath.
-func compress(inPath, outFilePath, subPath string) (err error) {
-	file, err := os.Create(outFilePath)
+// Creates a archive from inPath and writes it to w instead of a file on disk.
+func compressStream(inPath string, w io.Writer, subPath string) (err error) {
+	return compressStreamWithCompression(inPath, w, subPath, Gzip, TarOptions{}, nil)
+}
+
+// Same as compressStream but lets the caller choose the compression codec wrapping the tar
+// stream, pass TarOptions controlling which files are walked and how their headers look, and
+// an onFile hook invoked for every entry written (see Archiver.OnFile).
+func compressStreamWithCompression(inPath string, w io.Writer, subPath string, compression Compression, opts TarOptions, onFile func(string, *tar.Header) error) (err error) {
+	compressWriter, err := compressorFor(w, compression, opts.Parallel)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err != nil {
-			os.Remove(outFilePath)
-		}
-	}()
-
-	gzipWriter := gzip.NewWriter(file)
-	tarWriter := tar.NewWriter(gzipWriter)
+	tarWriter := tar.NewWriter(compressWriter)
 
 	var paths []string
-	if err := filepath.WalkDir(inPath, func(path string, di fs.DirEntry, err error) error {
-		paths = append(paths, path)
-		return err
-	}); err != nil {
-		return err
+	roots := append([]string{inPath}, opts.IncludePaths...)
+	for _, root := range roots {
+		if err := filepath.WalkDir(root, func(path string, di fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			excluded, err := isExcluded(path, subPath, opts.ExcludePatterns)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				if di.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			paths = append(paths, path)
+			return nil
+		}); err != nil {
+			return err
+		}
 	}
 	for _, p := range paths {
-		if err := writeTarGz(p, tarWriter); err != nil {
+		if err := writeTarGz(p, subPath, tarWriter, opts, onFile); err != nil {
 			return err
 		}
 	}
 
-	err = tarWriter.Close()
-	if err != nil {
+	if err := tarWriter.Close(); err != nil {
 		return err
 	}
 
-	err = gzipWriter.Close()
+	return compressWriter.Close()
+}
+
+// Reports whether path should be left out of the archive because its path relative to
+// subPath matches one of patterns.
+func isExcluded(path, subPath string, patterns []string) (bool, error) {
+	relPath, err := filepath.Rel(subPath, path)
+	if err != nil {
+		return false, err
+	}
+
+	return matchesAny(patterns, relPath)
+}
+
+// Options controls the archive format produced by CompressWithOptions.
+type Options struct {
+	// Compression selects the codec used to compress the resulting archive.
+	// Defaults to Gzip if left unset, matching Compress's historic .tar.gz behavior;
+	// set it to Uncompressed explicitly to skip compression.
+	Compression Compression
+	TarOptions
+}
+
+// CompressWithOptions behaves like Compress but lets callers pick the compression codec
+// used for the resulting archive via opts.Compression instead of always using gzip.
+func CompressWithOptions(inputFilePath, outputFilePath string, opts Options) (err error) {
+	inputFilePath = stripTrailingSlashes(inputFilePath)
+	inputFilePath, outputFilePath, err = makeAbsolute(inputFilePath, outputFilePath)
 	if err != nil {
 		return err
 	}
+	undoDir, err := mkdirAll(filepath.Dir(outputFilePath), 0755)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			undoDir()
+		}
+	}()
 
-	err = file.Close()
+	file, err := os.Create(outputFilePath)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			os.Remove(outputFilePath)
+		}
+	}()
+
+	if err = compressStreamWithCompression(inputFilePath, file, filepath.Dir(inputFilePath), opts.Compression, opts.TarOptions, nil); err != nil {
+		return err
+	}
 
-	return nil
+	return file.Close()
 }
 
-// Write path without the prefix in subPath to tar writer.
-func writeTarGz(path string, tarWriter *tar.Writer) error {
+// Write path without the prefix in subPath to tar writer, invoking onFile for the entry if set.
+func writeTarGz(path, subPath string, tarWriter *tar.Writer, opts TarOptions, onFile func(string, *tar.Header) error) error {
 	fileInfo, err := os.Lstat(path)
 	if err != nil {
 		return err
@@ -176,13 +265,34 @@ func writeTarGz(path string, tarWriter *tar.Writer) error {
 	if err != nil {
 		return err
 	}
-	header.Name = path
+
+	relName, err := filepath.Rel(subPath, path)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(relName)
+
+	if opts.Deterministic {
+		header.ModTime = time.Time{}
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+		header.Uid = 0
+		header.Gid = 0
+		header.Uname = ""
+		header.Gname = ""
+	}
 
 	err = tarWriter.WriteHeader(header)
 	if err != nil {
 		return err
 	}
 
+	if onFile != nil {
+		if err := onFile(path, header); err != nil {
+			return err
+		}
+	}
+
 	if !fileInfo.Mode().IsRegular() {
 		return nil
 	}